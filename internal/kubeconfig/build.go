@@ -0,0 +1,173 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// BuildOptions controls how BuildFluxClusterConfigMap, BuildFluxClusterSecret,
+// and BuildAll name and label the objects they produce.
+type BuildOptions struct {
+	// Namespace is set on every produced object's metadata.
+	Namespace string
+
+	// Audience is the OIDC audience Flux source-controller/kustomize-controller
+	// present when exchanging their ServiceAccount token for workload
+	// identity, written to the ConfigMap's "audience" key.
+	Audience string
+
+	// ServiceAccountName is written to the ConfigMap's "serviceAccountName"
+	// key.
+	ServiceAccountName string
+
+	// Labels is applied to every produced object's metadata, e.g. so a
+	// controller can watch for them with a label-selector predicate (see
+	// LoadFromSecret).
+	Labels map[string]string
+
+	// StripCredentials omits user credentials from BuildFluxClusterSecret
+	// entirely, producing a kubeconfig Secret that carries only the
+	// cluster's server and CA — the shape workload-identity flows need
+	// when the client never authenticates with a static credential.
+	StripCredentials bool
+}
+
+// fluxObjectName is the CAPI-style name flux-operator gives the
+// ConfigMap/Secret pair for a cluster.
+func fluxObjectName(clusterName string) string {
+	return clusterName + "-kubeconfig"
+}
+
+// BuildFluxClusterConfigMap produces the ConfigMap Flux source-controller
+// and kustomize-controller expect for workload-identity multi-tenancy:
+// apiServer, caFile, audience, and serviceAccountName keys.
+func BuildFluxClusterConfigMap(cd ClusterData, opts BuildOptions) (*corev1.ConfigMap, error) {
+	if cd.Name == "" {
+		return nil, fmt.Errorf("cluster data has no name")
+	}
+	if cd.Server == "" {
+		return nil, fmt.Errorf("cluster %q has no server", cd.Name)
+	}
+	if cd.CACert == "" {
+		return nil, fmt.Errorf("cluster %q has no CA certificate", cd.Name)
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fluxObjectName(cd.Name),
+			Namespace: opts.Namespace,
+			Labels:    opts.Labels,
+		},
+		Data: map[string]string{
+			"apiServer":          cd.Server,
+			"caFile":             cd.CACert,
+			"audience":           opts.Audience,
+			"serviceAccountName": opts.ServiceAccountName,
+		},
+	}, nil
+}
+
+// BuildFluxClusterSecret produces a Secret whose "value" key is a minimal
+// single-cluster kubeconfig YAML re-serialized from cd and user, for
+// workloads that need a ready-to-mount kubeconfig rather than the
+// workload-identity ConfigMap. If opts.StripCredentials is set, user is
+// ignored and the kubeconfig carries only the cluster's server and CA.
+func BuildFluxClusterSecret(cd ClusterData, user UserData, opts BuildOptions) (*corev1.Secret, error) {
+	if cd.Name == "" {
+		return nil, fmt.Errorf("cluster data has no name")
+	}
+	if cd.Server == "" {
+		return nil, fmt.Errorf("cluster %q has no server", cd.Name)
+	}
+	if cd.CACert == "" {
+		return nil, fmt.Errorf("cluster %q has no CA certificate", cd.Name)
+	}
+
+	cfg := &KubeConfig{
+		Kind:       "Config",
+		APIVersion: "v1",
+		Clusters: []NamedCluster{{
+			Name: cd.Name,
+			Cluster: ClusterConfig{
+				Server:                   cd.Server,
+				CertificateAuthorityData: base64.StdEncoding.EncodeToString([]byte(cd.CACert)),
+			},
+		}},
+		Contexts: []NamedContext{{
+			Name:    cd.Name,
+			Context: Context{Cluster: cd.Name, User: cd.Name},
+		}},
+		CurrentContext: cd.Name,
+		Users: []NamedUser{{
+			Name: cd.Name,
+			User: UserAuth{},
+		}},
+	}
+
+	if !opts.StripCredentials {
+		userAuth := &cfg.Users[0].User
+		if user.ClientCertificatePEM != "" {
+			userAuth.ClientCertificateData = base64.StdEncoding.EncodeToString([]byte(user.ClientCertificatePEM))
+		}
+		if user.ClientKeyPEM != "" {
+			userAuth.ClientKeyData = base64.StdEncoding.EncodeToString([]byte(user.ClientKeyPEM))
+		}
+		userAuth.Token = user.Token
+		userAuth.Exec = user.Exec
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kubeconfig for cluster %q: %w", cd.Name, err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fluxObjectName(cd.Name),
+			Namespace: opts.Namespace,
+			Labels:    opts.Labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"value": data,
+		},
+	}, nil
+}
+
+// BuildAll extracts every cluster from kubeconfigYAML via
+// ExtractAllFluxFields and builds its ConfigMap/Secret pair, naming each
+// `<clusterName>-kubeconfig` per CAPI convention. Plain extraction has no
+// per-cluster user, so the Secret either carries no credentials (if
+// opts.StripCredentials) or an empty user block a caller can fill in
+// separately, e.g. via ExtractFluxFieldsByClusterNameWithOptions.
+func BuildAll(kubeconfigYAML string, opts BuildOptions) ([]client.Object, error) {
+	clusters, err := ExtractAllFluxFields(kubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]client.Object, 0, len(clusters)*2)
+	for _, cd := range clusters {
+		cm, err := BuildFluxClusterConfigMap(cd, opts)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, cm)
+
+		secret, err := BuildFluxClusterSecret(cd, UserData{}, opts)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, secret)
+	}
+
+	return objs, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"testing"
+)
+
+const buildTestCACert = `-----BEGIN CERTIFICATE-----
+MIICtest123
+-----END CERTIFICATE-----`
+
+func TestBuildFluxClusterConfigMap(t *testing.T) {
+	cd := ClusterData{Name: "workload", Server: "https://workload-cluster:6443", CACert: buildTestCACert}
+	opts := BuildOptions{
+		Namespace:          "flux-system",
+		Audience:           "sts.amazonaws.com",
+		ServiceAccountName: "flux-workload-identity",
+		Labels:             map[string]string{"flux.io/kubeconfig": "true"},
+	}
+
+	cm, err := BuildFluxClusterConfigMap(cd, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cm.Name != "workload-kubeconfig" {
+		t.Errorf("expected name %q, got %q", "workload-kubeconfig", cm.Name)
+	}
+	if cm.Namespace != "flux-system" {
+		t.Errorf("expected namespace %q, got %q", "flux-system", cm.Namespace)
+	}
+	if cm.Data["apiServer"] != cd.Server {
+		t.Errorf("expected apiServer %q, got %q", cd.Server, cm.Data["apiServer"])
+	}
+	if cm.Data["caFile"] != cd.CACert {
+		t.Errorf("expected caFile %q, got %q", cd.CACert, cm.Data["caFile"])
+	}
+	if cm.Data["audience"] != opts.Audience {
+		t.Errorf("expected audience %q, got %q", opts.Audience, cm.Data["audience"])
+	}
+	if cm.Data["serviceAccountName"] != opts.ServiceAccountName {
+		t.Errorf("expected serviceAccountName %q, got %q", opts.ServiceAccountName, cm.Data["serviceAccountName"])
+	}
+	if cm.Labels["flux.io/kubeconfig"] != "true" {
+		t.Errorf("expected label to be propagated")
+	}
+}
+
+func TestBuildFluxClusterConfigMap_MissingFields(t *testing.T) {
+	tests := []struct {
+		name string
+		cd   ClusterData
+	}{
+		{name: "no name", cd: ClusterData{Server: "https://x:6443", CACert: buildTestCACert}},
+		{name: "no server", cd: ClusterData{Name: "x", CACert: buildTestCACert}},
+		{name: "no CA", cd: ClusterData{Name: "x", Server: "https://x:6443"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildFluxClusterConfigMap(tt.cd, BuildOptions{}); err == nil {
+				t.Error("expected error but got none")
+			}
+		})
+	}
+}
+
+func TestBuildFluxClusterSecret(t *testing.T) {
+	cd := ClusterData{Name: "workload", Server: "https://workload-cluster:6443", CACert: buildTestCACert}
+	user := UserData{Name: "workload", Token: "sometoken123"}
+
+	secret, err := BuildFluxClusterSecret(cd, user, BuildOptions{Namespace: "flux-system"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if secret.Name != "workload-kubeconfig" {
+		t.Errorf("expected name %q, got %q", "workload-kubeconfig", secret.Name)
+	}
+
+	value := string(secret.Data["value"])
+	for _, key := range []string{"current-context:", "certificate-authority-data:", "server:"} {
+		if !containsSubstring(value, key) {
+			t.Errorf("expected embedded kubeconfig to use kubeconfig key %q, got:\n%s", key, value)
+		}
+	}
+
+	var roundTripped KubeConfig
+	if err := parseInto(secret.Data["value"], &roundTripped); err != nil {
+		t.Fatalf("failed to parse produced kubeconfig: %v", err)
+	}
+
+	if len(roundTripped.Clusters) != 1 || roundTripped.Clusters[0].Cluster.Server != cd.Server {
+		t.Errorf("expected re-serialized kubeconfig to carry the cluster server")
+	}
+
+	cd2, err := ExtractFluxFieldsByClusterName(string(secret.Data["value"]), "workload")
+	if err != nil {
+		t.Fatalf("unexpected error re-extracting: %v", err)
+	}
+	if cd2.CACert != cd.CACert {
+		t.Errorf("expected CA cert to round-trip, got %q", cd2.CACert)
+	}
+
+	_, ud, err := ExtractFluxFieldsByContext(string(secret.Data["value"]), "workload")
+	if err != nil {
+		t.Fatalf("unexpected error re-extracting context: %v", err)
+	}
+	if ud.Token != user.Token {
+		t.Errorf("expected token to round-trip, got %q", ud.Token)
+	}
+}
+
+func TestBuildFluxClusterSecret_StripCredentials(t *testing.T) {
+	cd := ClusterData{Name: "workload", Server: "https://workload-cluster:6443", CACert: buildTestCACert}
+	user := UserData{Name: "workload", Token: "sometoken123"}
+
+	secret, err := BuildFluxClusterSecret(cd, user, BuildOptions{StripCredentials: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, ud, err := ExtractFluxFieldsByContext(string(secret.Data["value"]), "workload")
+	if err != nil {
+		t.Fatalf("unexpected error re-extracting context: %v", err)
+	}
+	if ud.Token != "" {
+		t.Errorf("expected token to be stripped, got %q", ud.Token)
+	}
+}
+
+func TestBuildAll(t *testing.T) {
+	kubeconfigYAML := `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0MTIzCi0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://first-cluster:6443
+  name: first-cluster
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0NDU2Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://second-cluster:6443
+  name: second-cluster`
+
+	objs, err := BuildAll(kubeconfigYAML, BuildOptions{Namespace: "flux-system", StripCredentials: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(objs) != 4 {
+		t.Fatalf("expected 4 objects (ConfigMap+Secret per cluster), got %d", len(objs))
+	}
+	if objs[0].GetName() != "first-cluster-kubeconfig" {
+		t.Errorf("expected first object name %q, got %q", "first-cluster-kubeconfig", objs[0].GetName())
+	}
+	if objs[2].GetName() != "second-cluster-kubeconfig" {
+		t.Errorf("expected third object name %q, got %q", "second-cluster-kubeconfig", objs[2].GetName())
+	}
+}
+
+// parseInto is a thin wrapper around parseKubeConfig for tests that need a
+// *KubeConfig rather than the (KubeConfig, error) pair.
+func parseInto(data []byte, out *KubeConfig) error {
+	cfg, err := parseKubeConfig(string(data))
+	if err != nil {
+		return err
+	}
+	*out = cfg
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultExecTimeout bounds how long an exec credential plugin is allowed
+// to run when LoadOptions.ExecTimeout is unset.
+const defaultExecTimeout = 30 * time.Second
+
+// LoadOptions controls how a kubeconfig's on-disk file references and exec
+// credential plugins are resolved. The zero value is safe to use and
+// disables exec entirely, resolving file references relative to the
+// process's working directory.
+type LoadOptions struct {
+	// BaseDir is used to resolve relative certificate-authority,
+	// client-certificate, and client-key file paths, matching clientcmd's
+	// behaviour of resolving them relative to the kubeconfig's own
+	// location rather than the process's working directory. Empty means
+	// resolve relative to the process's working directory.
+	BaseDir string
+
+	// AllowExec permits running a user's exec credential plugin to obtain a
+	// bearer token. When false, encountering an exec block returns an
+	// ExecCredentialRequiredError instead of invoking it, so callers can
+	// decide whether it's safe to run in their environment.
+	AllowExec bool
+
+	// ExecTimeout bounds how long an exec credential plugin may run.
+	// Defaults to defaultExecTimeout if zero.
+	ExecTimeout time.Duration
+
+	// EnvAllowlist restricts which process environment variables are
+	// passed through to the exec credential plugin, in addition to the
+	// variables it declares in its own exec.env block. A nil slice passes
+	// none of the process environment through.
+	EnvAllowlist []string
+
+	// ExecProvider invokes exec credential plugins. Defaults to a provider
+	// that runs the plugin as a subprocess if nil. Tests and callers that
+	// want to avoid shelling out can supply a fake.
+	ExecProvider ExecCredentialProvider
+}
+
+// ExecCredentialRequiredError is returned when a kubeconfig user requires
+// an exec credential plugin to obtain a token but LoadOptions.AllowExec is
+// false.
+type ExecCredentialRequiredError struct {
+	UserName string
+	Exec     *ExecConfig
+}
+
+func (e *ExecCredentialRequiredError) Error() string {
+	return fmt.Sprintf("user %q requires running exec credential plugin %q; set LoadOptions.AllowExec to allow it", e.UserName, e.Exec.Command)
+}
+
+// ExecCredentialProvider resolves a bearer token from a kubeconfig exec
+// credential plugin block, e.g. `aws eks get-token` or
+// `gke-gcloud-auth-plugin`.
+type ExecCredentialProvider interface {
+	GetToken(ctx context.Context, exec *ExecConfig, opts LoadOptions) (string, error)
+}
+
+// execCredential is the subset of the client.authentication.k8s.io
+// ExecCredential response that the default provider needs.
+type execCredential struct {
+	Status struct {
+		Token string `json:"token"`
+	} `json:"status"`
+}
+
+// subprocessExecCredentialProvider is the default ExecCredentialProvider,
+// running the plugin as a subprocess and parsing its ExecCredential JSON
+// response from stdout.
+type subprocessExecCredentialProvider struct{}
+
+func (subprocessExecCredentialProvider) GetToken(ctx context.Context, execCfg *ExecConfig, opts LoadOptions) (string, error) {
+	if execCfg.Command == "" {
+		return "", fmt.Errorf("exec command is empty")
+	}
+
+	cmd := exec.CommandContext(ctx, execCfg.Command, execCfg.Args...)
+	cmd.Env = execEnv(execCfg, opts.EnvAllowlist)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("exec plugin %q failed: %w (stderr: %s)", execCfg.Command, err, stderr.String())
+	}
+
+	var cred execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return "", fmt.Errorf("failed to parse exec plugin %q output as ExecCredential: %w", execCfg.Command, err)
+	}
+
+	if cred.Status.Token == "" {
+		return "", fmt.Errorf("exec plugin %q returned no status.token", execCfg.Command)
+	}
+
+	return cred.Status.Token, nil
+}
+
+// execEnv builds the environment passed to an exec credential plugin: the
+// variables declared in the exec block itself, plus any process
+// environment variables named in envAllowlist.
+func execEnv(execCfg *ExecConfig, envAllowlist []string) []string {
+	env := os.Environ()
+	filtered := make([]string, 0, len(execCfg.Env)+len(envAllowlist))
+	allowed := make(map[string]bool, len(envAllowlist))
+	for _, name := range envAllowlist {
+		allowed[name] = true
+	}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if allowed[name] {
+			filtered = append(filtered, kv)
+		}
+	}
+	for _, v := range execCfg.Env {
+		filtered = append(filtered, v.Name+"="+v.Value)
+	}
+	return filtered
+}
+
+// resolveExecToken runs the user's exec credential plugin (if allowed) to
+// obtain a bearer token, or returns an ExecCredentialRequiredError so the
+// caller can decide how to proceed.
+func resolveExecToken(userName string, execCfg *ExecConfig, opts LoadOptions) (string, error) {
+	if !opts.AllowExec {
+		return "", &ExecCredentialRequiredError{UserName: userName, Exec: execCfg}
+	}
+
+	timeout := opts.ExecTimeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	provider := opts.ExecProvider
+	if provider == nil {
+		provider = subprocessExecCredentialProvider{}
+	}
+
+	token, err := provider.GetToken(ctx, execCfg, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute credential plugin for user %q: %w", userName, err)
+	}
+
+	return token, nil
+}
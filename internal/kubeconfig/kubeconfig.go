@@ -4,28 +4,140 @@
 package kubeconfig
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"sigs.k8s.io/yaml"
 )
 
-// KubeConfig represents the minimal structure needed to extract
-// API server address and CA certificate from a kubeconfig.
+// KubeConfig represents the structure of a kubeconfig file, mirroring the
+// subset of fields exposed by k8s.io/client-go/tools/clientcmd/api that
+// Flux workload-identity code needs: clusters, contexts, users, the
+// current-context pointer, and preferences.
 type KubeConfig struct {
-	Clusters []Cluster `yaml:"clusters"`
+	Kind           string                 `json:"kind,omitempty"`
+	APIVersion     string                 `json:"apiVersion,omitempty"`
+	Clusters       []NamedCluster         `json:"clusters"`
+	Contexts       []NamedContext         `json:"contexts,omitempty"`
+	Users          []NamedUser            `json:"users,omitempty"`
+	CurrentContext string                 `json:"current-context,omitempty"`
+	Preferences    map[string]interface{} `json:"preferences,omitempty"`
 }
 
-// Cluster represents a cluster entry in the kubeconfig.
-type Cluster struct {
-	Name    string        `yaml:"name"`
-	Cluster ClusterConfig `yaml:"cluster"`
+// NamedCluster represents a cluster entry in the kubeconfig.
+type NamedCluster struct {
+	Name    string        `json:"name"`
+	Cluster ClusterConfig `json:"cluster"`
 }
 
 // ClusterConfig contains the cluster configuration details.
 type ClusterConfig struct {
-	Server                   string `yaml:"server"`
-	CertificateAuthorityData string `yaml:"certificate-authority-data,omitempty"`
+	Server                   string `json:"server"`
+	CertificateAuthorityData string `json:"certificate-authority-data,omitempty"`
+	CertificateAuthority     string `json:"certificate-authority,omitempty"`
+	InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify,omitempty"`
+	TLSServerName            string `json:"tls-server-name,omitempty"`
+	ProxyURL                 string `json:"proxy-url,omitempty"`
+}
+
+// NamedContext represents a context entry in the kubeconfig, binding a
+// cluster and a user together under a name.
+type NamedContext struct {
+	Name    string  `json:"name"`
+	Context Context `json:"context"`
+}
+
+// Context references the cluster and user a kubeconfig context uses.
+type Context struct {
+	Cluster   string `json:"cluster"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamedUser represents a user entry in the kubeconfig.
+type NamedUser struct {
+	Name string   `json:"name"`
+	User UserAuth `json:"user"`
+}
+
+// UserAuth contains the credential material for a kubeconfig user.
+type UserAuth struct {
+	ClientCertificateData string              `json:"client-certificate-data,omitempty"`
+	ClientCertificate     string              `json:"client-certificate,omitempty"`
+	ClientKeyData         string              `json:"client-key-data,omitempty"`
+	ClientKey             string              `json:"client-key,omitempty"`
+	Token                 string              `json:"token,omitempty"`
+	TokenFile             string              `json:"tokenFile,omitempty"`
+	As                    string              `json:"as,omitempty"`
+	Username              string              `json:"username,omitempty"`
+	Password              string              `json:"password,omitempty"`
+	AuthProvider          *AuthProviderConfig `json:"auth-provider,omitempty"`
+	Exec                  *ExecConfig         `json:"exec,omitempty"`
+}
+
+// AuthProviderConfig represents a `users[].user.auth-provider` plugin block,
+// e.g. the gcp/azure/oidc auth providers clientcmd supports.
+type AuthProviderConfig struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// ExecConfig represents a `users[].user.exec` credential plugin block, e.g.
+// `aws eks get-token` or `gke-gcloud-auth-plugin`.
+type ExecConfig struct {
+	Command    string       `json:"command"`
+	Args       []string     `json:"args,omitempty"`
+	Env        []ExecEnvVar `json:"env,omitempty"`
+	APIVersion string       `json:"apiVersion,omitempty"`
+}
+
+// ExecEnvVar is a single environment variable passed to an exec credential
+// plugin.
+type ExecEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ClusterData holds the fields extracted from a kubeconfig cluster entry
+// that Flux workload identity needs.
+type ClusterData struct {
+	Name   string
+	Server string
+	CACert string
+
+	// Reachable and ValidationError are only populated by
+	// ExtractAllFluxFieldsWithValidation; plain extraction leaves them
+	// zero-valued.
+	Reachable       bool
+	ValidationError string
+}
+
+// UserData holds the credential material extracted from a kubeconfig user
+// entry, so downstream Flux workload-identity code can build full
+// connection secrets rather than just server+CA.
+//
+// Unlike UserAuth's *-data fields, ClientCertificatePEM and ClientKeyPEM
+// hold decoded PEM, not base64 — they're the already-resolved form of
+// whichever of client-certificate-data/client-certificate (and the key
+// equivalents) the source kubeconfig used.
+type UserData struct {
+	Name                 string
+	ClientCertificatePEM string
+	ClientKeyPEM         string
+	Token                string
+	Exec                 *ExecConfig
+}
+
+// ContextInfo describes a named context in a kubeconfig, as returned by
+// ListContexts.
+type ContextInfo struct {
+	Name      string
+	Cluster   string
+	User      string
+	IsCurrent bool
 }
 
 // ExtractFluxFields parses a kubeconfig YAML and extracts the fields
@@ -45,8 +157,8 @@ func ExtractFluxFields(kubeconfigYAML string) (server, caCert string, err error)
 		return "", "", fmt.Errorf("no clusters found in kubeconfig")
 	}
 
-	// Use the first cluster for now
-	// TODO: Support cluster selection by name in future enhancement
+	// Use the first cluster; callers that need a specific one should use
+	// ExtractFluxFieldsByClusterName instead.
 	cluster := config.Clusters[0].Cluster
 
 	if cluster.Server == "" {
@@ -68,3 +180,316 @@ func ExtractFluxFields(kubeconfigYAML string) (server, caCert string, err error)
 
 	return server, caCert, nil
 }
+
+// ExtractAllFluxFields parses a kubeconfig YAML and extracts the Flux
+// workload identity fields (server and CA certificate) for every cluster
+// it contains, preserving the order in which they appear.
+func ExtractAllFluxFields(kubeconfigYAML string) ([]ClusterData, error) {
+	return ExtractAllFluxFieldsWithOptions(kubeconfigYAML, LoadOptions{})
+}
+
+// ExtractAllFluxFieldsWithOptions is ExtractAllFluxFields with control over
+// how on-disk CA file references are resolved, see LoadOptions.
+func ExtractAllFluxFieldsWithOptions(kubeconfigYAML string, opts LoadOptions) ([]ClusterData, error) {
+	config, err := parseKubeConfig(kubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Clusters) == 0 {
+		return nil, fmt.Errorf("no clusters found in kubeconfig")
+	}
+
+	clusters := make([]ClusterData, 0, len(config.Clusters))
+	for _, named := range config.Clusters {
+		cd, err := clusterData(named, opts)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cd)
+	}
+
+	return clusters, nil
+}
+
+// ExtractAllFluxFieldsWithValidation is ExtractAllFluxFieldsWithOptions
+// followed by a live Validate call against every extracted cluster. A
+// cluster that fails validation is not dropped or treated as an overall
+// error — it's returned with Reachable false and ValidationError set, so
+// callers ingesting multiple clusters can mark the unreachable ones rather
+// than silently propagating a stale CA bundle.
+func ExtractAllFluxFieldsWithValidation(ctx context.Context, kubeconfigYAML string, opts LoadOptions, vopts ValidateOptions) ([]ClusterData, error) {
+	clusters, err := ExtractAllFluxFieldsWithOptions(kubeconfigYAML, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range clusters {
+		if err := Validate(ctx, clusters[i], vopts); err != nil {
+			clusters[i].ValidationError = err.Error()
+			continue
+		}
+		clusters[i].Reachable = true
+	}
+
+	return clusters, nil
+}
+
+// ExtractFluxFieldsByContext resolves contextName (or, if empty, the
+// kubeconfig's current-context) to its cluster and user entries and
+// returns the Flux workload identity fields for both. This is the
+// multi-context equivalent of ExtractFluxFields, which always uses the
+// first cluster regardless of current-context.
+func ExtractFluxFieldsByContext(kubeconfigYAML, contextName string) (ClusterData, UserData, error) {
+	return ExtractFluxFieldsByContextWithOptions(kubeconfigYAML, contextName, LoadOptions{})
+}
+
+// ExtractFluxFieldsByContextWithOptions is ExtractFluxFieldsByContext with
+// control over how on-disk CA/cert/key file references and exec credential
+// plugins are resolved, see LoadOptions.
+func ExtractFluxFieldsByContextWithOptions(kubeconfigYAML, contextName string, opts LoadOptions) (ClusterData, UserData, error) {
+	config, err := parseKubeConfig(kubeconfigYAML)
+	if err != nil {
+		return ClusterData{}, UserData{}, err
+	}
+
+	name := contextName
+	if name == "" {
+		name = config.CurrentContext
+	}
+	if name == "" {
+		return ClusterData{}, UserData{}, fmt.Errorf("no context name given and current-context is not set in kubeconfig")
+	}
+
+	ctx, ok := findContext(config, name)
+	if !ok {
+		return ClusterData{}, UserData{}, fmt.Errorf("context %q not found in kubeconfig", name)
+	}
+
+	named, ok := findCluster(config, ctx.Context.Cluster)
+	if !ok {
+		return ClusterData{}, UserData{}, fmt.Errorf("cluster %q referenced by context %q not found in kubeconfig", ctx.Context.Cluster, name)
+	}
+
+	cd, err := clusterData(named, opts)
+	if err != nil {
+		return ClusterData{}, UserData{}, err
+	}
+
+	var ud UserData
+	if ctx.Context.User != "" {
+		namedUser, ok := findUser(config, ctx.Context.User)
+		if !ok {
+			return ClusterData{}, UserData{}, fmt.Errorf("user %q referenced by context %q not found in kubeconfig", ctx.Context.User, name)
+		}
+		ud, err = userData(namedUser, opts)
+		if err != nil {
+			return ClusterData{}, UserData{}, err
+		}
+	}
+
+	return cd, ud, nil
+}
+
+// ExtractFluxFieldsByClusterName returns the Flux workload identity fields
+// for the cluster entry with the given name, regardless of which (if any)
+// context references it.
+func ExtractFluxFieldsByClusterName(kubeconfigYAML, clusterName string) (ClusterData, error) {
+	return ExtractFluxFieldsByClusterNameWithOptions(kubeconfigYAML, clusterName, LoadOptions{})
+}
+
+// ExtractFluxFieldsByClusterNameWithOptions is ExtractFluxFieldsByClusterName
+// with control over how on-disk CA file references are resolved, see
+// LoadOptions.
+func ExtractFluxFieldsByClusterNameWithOptions(kubeconfigYAML, clusterName string, opts LoadOptions) (ClusterData, error) {
+	config, err := parseKubeConfig(kubeconfigYAML)
+	if err != nil {
+		return ClusterData{}, err
+	}
+
+	named, ok := findCluster(config, clusterName)
+	if !ok {
+		return ClusterData{}, fmt.Errorf("cluster %q not found in kubeconfig", clusterName)
+	}
+
+	return clusterData(named, opts)
+}
+
+// ListContexts returns every named context in the kubeconfig, in the order
+// they appear, flagging which one (if any) matches current-context.
+func ListContexts(kubeconfigYAML string) ([]ContextInfo, error) {
+	config, err := parseKubeConfig(kubeconfigYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]ContextInfo, 0, len(config.Contexts))
+	for _, named := range config.Contexts {
+		contexts = append(contexts, ContextInfo{
+			Name:      named.Name,
+			Cluster:   named.Context.Cluster,
+			User:      named.Context.User,
+			IsCurrent: named.Name == config.CurrentContext,
+		})
+	}
+
+	return contexts, nil
+}
+
+// parseKubeConfig unmarshals kubeconfig YAML into a KubeConfig.
+func parseKubeConfig(kubeconfigYAML string) (KubeConfig, error) {
+	var config KubeConfig
+	if err := yaml.Unmarshal([]byte(kubeconfigYAML), &config); err != nil {
+		return KubeConfig{}, fmt.Errorf("failed to parse kubeconfig YAML: %w", err)
+	}
+	return config, nil
+}
+
+// findContext looks up a named context by name.
+func findContext(config KubeConfig, name string) (NamedContext, bool) {
+	for _, ctx := range config.Contexts {
+		if ctx.Name == name {
+			return ctx, true
+		}
+	}
+	return NamedContext{}, false
+}
+
+// findCluster looks up a named cluster by name.
+func findCluster(config KubeConfig, name string) (NamedCluster, bool) {
+	for _, cluster := range config.Clusters {
+		if cluster.Name == name {
+			return cluster, true
+		}
+	}
+	return NamedCluster{}, false
+}
+
+// findUser looks up a named user by name.
+func findUser(config KubeConfig, name string) (NamedUser, bool) {
+	for _, user := range config.Users {
+		if user.Name == name {
+			return user, true
+		}
+	}
+	return NamedUser{}, false
+}
+
+// clusterData validates and decodes a NamedCluster into a ClusterData. The
+// CA certificate is read from certificate-authority-data if set, otherwise
+// resolved from the certificate-authority file reference per opts.BaseDir.
+func clusterData(named NamedCluster, opts LoadOptions) (ClusterData, error) {
+	cluster := named.Cluster
+
+	if cluster.Server == "" {
+		return ClusterData{}, fmt.Errorf("server field is empty in kubeconfig cluster %q", named.Name)
+	}
+
+	caCert, err := resolveCACert(cluster, opts)
+	if err != nil {
+		return ClusterData{}, fmt.Errorf("failed to resolve CA certificate for cluster %q: %w", named.Name, err)
+	}
+
+	if caCert == "" {
+		return ClusterData{}, fmt.Errorf("certificate-authority-data field is empty in kubeconfig cluster %q", named.Name)
+	}
+
+	return ClusterData{
+		Name:   named.Name,
+		Server: cluster.Server,
+		CACert: caCert,
+	}, nil
+}
+
+// resolveCACert returns the PEM-encoded CA certificate for a cluster entry,
+// preferring the inline base64 certificate-authority-data field and falling
+// back to reading the certificate-authority file reference.
+func resolveCACert(cluster ClusterConfig, opts LoadOptions) (string, error) {
+	if cluster.CertificateAuthorityData != "" {
+		caBytes, err := base64.StdEncoding.DecodeString(cluster.CertificateAuthorityData)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode certificate-authority-data: %w", err)
+		}
+		return string(caBytes), nil
+	}
+
+	if cluster.CertificateAuthority == "" {
+		return "", nil
+	}
+
+	path := resolvePath(cluster.CertificateAuthority, opts.BaseDir)
+	caBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read certificate-authority file %q: %w", path, err)
+	}
+	return string(caBytes), nil
+}
+
+// userData converts a NamedUser into a UserData, resolving client
+// certificate/key file references and, if allowed by opts, running an exec
+// credential plugin to obtain a bearer token.
+func userData(named NamedUser, opts LoadOptions) (UserData, error) {
+	user := named.User
+
+	certPEM, err := resolveFileOrData(user.ClientCertificateData, user.ClientCertificate, opts.BaseDir)
+	if err != nil {
+		return UserData{}, fmt.Errorf("failed to resolve client-certificate for user %q: %w", named.Name, err)
+	}
+
+	keyPEM, err := resolveFileOrData(user.ClientKeyData, user.ClientKey, opts.BaseDir)
+	if err != nil {
+		return UserData{}, fmt.Errorf("failed to resolve client-key for user %q: %w", named.Name, err)
+	}
+
+	ud := UserData{
+		Name:                 named.Name,
+		ClientCertificatePEM: certPEM,
+		ClientKeyPEM:         keyPEM,
+		Token:                user.Token,
+		Exec:                 user.Exec,
+	}
+
+	if ud.Token == "" && user.Exec != nil {
+		token, err := resolveExecToken(named.Name, user.Exec, opts)
+		if err != nil {
+			return UserData{}, err
+		}
+		ud.Token = token
+	}
+
+	return ud, nil
+}
+
+// resolveFileOrData returns the PEM content for a credential field that may
+// be supplied either inline as base64 data or as a path to a file on disk,
+// matching clientcmd's *-data vs. plain-field precedence.
+func resolveFileOrData(data, path, baseDir string) (string, error) {
+	if data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode base64 data: %w", err)
+		}
+		return string(decoded), nil
+	}
+
+	if path == "" {
+		return "", nil
+	}
+
+	resolved := resolvePath(path, baseDir)
+	contents, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %q: %w", resolved, err)
+	}
+	return string(contents), nil
+}
+
+// resolvePath joins a relative file reference with baseDir, matching
+// clientcmd's semantics of resolving paths relative to the kubeconfig's
+// location rather than the process's working directory.
+func resolvePath(path, baseDir string) string {
+	if filepath.IsAbs(path) || baseDir == "" {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
@@ -35,8 +35,8 @@ preferences: {}
 users:
 - name: capi-helloworld-admin
   user:
-    client-certificate-data: LS0tLS1...
-    client-key-data: LS0tLS1...`,
+    client-certificate-data: ZmFrZS1jZXJ0LWRhdGE=
+    client-key-data: ZmFrZS1rZXktZGF0YQ==`,
 			expectedServer: "https://172.18.0.3:6443",
 			expectedCACert: `-----BEGIN CERTIFICATE-----
 MIICtest123
@@ -276,7 +276,7 @@ clusters:
     server: https://second-cluster:6443
   name: second-cluster`,
 			expectError:   true,
-			errorContains: `failed to decode certificate-authority-data for cluster "second-cluster"`,
+			errorContains: `failed to resolve CA certificate for cluster "second-cluster"`,
 		},
 	}
 
@@ -331,3 +331,181 @@ clusters:
 		})
 	}
 }
+
+const multiContextKubeconfigYAML = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0MTIzCi0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://management-cluster:6443
+  name: management
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0NDU2Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://workload-cluster:6443
+  name: workload
+contexts:
+- context:
+    cluster: management
+    user: management-admin
+  name: management-admin@management
+- context:
+    cluster: workload
+    user: workload-admin
+  name: workload-admin@workload
+current-context: workload-admin@workload
+kind: Config
+preferences: {}
+users:
+- name: management-admin
+  user:
+    client-certificate-data: ZmFrZS1jZXJ0LWRhdGE=
+    client-key-data: ZmFrZS1rZXktZGF0YQ==
+- name: workload-admin
+  user:
+    token: sometoken123`
+
+func TestExtractFluxFieldsByContext(t *testing.T) {
+	tests := []struct {
+		name           string
+		contextName    string
+		expectedServer string
+		expectedUser   string
+		expectedToken  string
+		expectError    bool
+		errorContains  string
+	}{
+		{
+			name:           "explicit context",
+			contextName:    "management-admin@management",
+			expectedServer: "https://management-cluster:6443",
+			expectedUser:   "management-admin",
+		},
+		{
+			name:           "falls back to current-context",
+			contextName:    "",
+			expectedServer: "https://workload-cluster:6443",
+			expectedUser:   "workload-admin",
+			expectedToken:  "sometoken123",
+		},
+		{
+			name:          "unknown context",
+			contextName:   "does-not-exist",
+			expectError:   true,
+			errorContains: `context "does-not-exist" not found in kubeconfig`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd, ud, err := ExtractFluxFieldsByContext(multiContextKubeconfigYAML, tt.contextName)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+					return
+				}
+				if tt.errorContains != "" && !containsSubstring(err.Error(), tt.errorContains) {
+					t.Errorf("expected error to contain %q, got: %v", tt.errorContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if cd.Server != tt.expectedServer {
+				t.Errorf("expected server %q, got %q", tt.expectedServer, cd.Server)
+			}
+			if ud.Name != tt.expectedUser {
+				t.Errorf("expected user %q, got %q", tt.expectedUser, ud.Name)
+			}
+			if ud.Token != tt.expectedToken {
+				t.Errorf("expected token %q, got %q", tt.expectedToken, ud.Token)
+			}
+		})
+	}
+}
+
+func TestExtractFluxFieldsByClusterName(t *testing.T) {
+	tests := []struct {
+		name           string
+		clusterName    string
+		expectedServer string
+		expectError    bool
+		errorContains  string
+	}{
+		{
+			name:           "existing cluster",
+			clusterName:    "workload",
+			expectedServer: "https://workload-cluster:6443",
+		},
+		{
+			name:          "unknown cluster",
+			clusterName:   "does-not-exist",
+			expectError:   true,
+			errorContains: `cluster "does-not-exist" not found in kubeconfig`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd, err := ExtractFluxFieldsByClusterName(multiContextKubeconfigYAML, tt.clusterName)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+					return
+				}
+				if tt.errorContains != "" && !containsSubstring(err.Error(), tt.errorContains) {
+					t.Errorf("expected error to contain %q, got: %v", tt.errorContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if cd.Server != tt.expectedServer {
+				t.Errorf("expected server %q, got %q", tt.expectedServer, cd.Server)
+			}
+		})
+	}
+}
+
+func TestListContexts(t *testing.T) {
+	contexts, err := ListContexts(multiContextKubeconfigYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ContextInfo{
+		{Name: "management-admin@management", Cluster: "management", User: "management-admin", IsCurrent: false},
+		{Name: "workload-admin@workload", Cluster: "workload", User: "workload-admin", IsCurrent: true},
+	}
+
+	if len(contexts) != len(expected) {
+		t.Fatalf("expected %d contexts, got %d", len(expected), len(contexts))
+	}
+
+	for i, exp := range expected {
+		if contexts[i] != exp {
+			t.Errorf("context %d: expected %+v, got %+v", i, exp, contexts[i])
+		}
+	}
+}
+
+// containsSubstring reports whether s contains substr.
+func containsSubstring(s, substr string) bool {
+	if substr == "" {
+		return true
+	}
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// secretKubeconfigDataKeys lists the Secret data keys that may hold a raw
+// kubeconfig, in lookup order. "value" is flux-operator's own convention;
+// "kubeconfig" is the key CAPI writes for `<cluster>-kubeconfig` Secrets.
+var secretKubeconfigDataKeys = []string{"value", "kubeconfig"}
+
+// Source is a single kubeconfig to be folded into a merged KubeConfig by
+// Merge. Name identifies the source in error messages and is not written
+// to the merged output.
+type Source struct {
+	Name string
+	YAML string
+}
+
+// YAMLSource wraps a raw kubeconfig YAML string as a merge Source.
+func YAMLSource(name, kubeconfigYAML string) Source {
+	return Source{Name: name, YAML: kubeconfigYAML}
+}
+
+// FileSource reads a kubeconfig file from disk and wraps it as a merge
+// Source.
+func FileSource(path string) (Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Source{}, fmt.Errorf("failed to read kubeconfig file %q: %w", path, err)
+	}
+	return Source{Name: path, YAML: string(data)}, nil
+}
+
+// SecretSource extracts a kubeconfig from a Secret's data, looking at
+// `.data.value` (flux-operator's own convention) and falling back to
+// `.data.kubeconfig` (the CAPI `<cluster>-kubeconfig` Secret convention).
+func SecretSource(secret *corev1.Secret) (Source, error) {
+	for _, key := range secretKubeconfigDataKeys {
+		if data, ok := secret.Data[key]; ok {
+			return Source{Name: fmt.Sprintf("%s/%s", secret.Namespace, secret.Name), YAML: string(data)}, nil
+		}
+	}
+	return Source{}, fmt.Errorf("secret %s/%s has no %v data key", secret.Namespace, secret.Name, secretKubeconfigDataKeys)
+}
+
+// Merge combines the kubeconfigs from sources into a single canonical
+// KubeConfig. Clusters, contexts, and users keep their original names
+// unless a later source collides with one already merged in, in which case
+// the colliding entry (and every reference to it within its own source) is
+// renamed `<name>-2`, `<name>-3`, and so on — the same rename-on-collision
+// behaviour as `kubectl config view --merge`. The current-context of the
+// first source that has one wins.
+func Merge(sources ...Source) (*KubeConfig, error) {
+	merged := &KubeConfig{Kind: "Config", APIVersion: "v1"}
+
+	clusterNames := make(map[string]bool)
+	contextNames := make(map[string]bool)
+	userNames := make(map[string]bool)
+
+	for _, src := range sources {
+		cfg, err := parseKubeConfig(src.YAML)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: %w", src.Name, err)
+		}
+
+		clusterRename := make(map[string]string, len(cfg.Clusters))
+		for _, cluster := range cfg.Clusters {
+			newName := uniqueName(cluster.Name, clusterNames)
+			clusterRename[cluster.Name] = newName
+			clusterNames[newName] = true
+			cluster.Name = newName
+			merged.Clusters = append(merged.Clusters, cluster)
+		}
+
+		userRename := make(map[string]string, len(cfg.Users))
+		for _, user := range cfg.Users {
+			newName := uniqueName(user.Name, userNames)
+			userRename[user.Name] = newName
+			userNames[newName] = true
+			user.Name = newName
+			merged.Users = append(merged.Users, user)
+		}
+
+		for _, namedCtx := range cfg.Contexts {
+			isCurrent := namedCtx.Name == cfg.CurrentContext
+			if renamed, ok := clusterRename[namedCtx.Context.Cluster]; ok {
+				namedCtx.Context.Cluster = renamed
+			}
+			if renamed, ok := userRename[namedCtx.Context.User]; ok {
+				namedCtx.Context.User = renamed
+			}
+			newName := uniqueName(namedCtx.Name, contextNames)
+			contextNames[newName] = true
+			namedCtx.Name = newName
+			merged.Contexts = append(merged.Contexts, namedCtx)
+
+			if isCurrent && merged.CurrentContext == "" {
+				merged.CurrentContext = newName
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// MergeYAML merges sources like Merge and marshals the result to
+// deterministic kubeconfig YAML, safe to reconcile into a downstream
+// Secret without producing spurious diffs between reconciliations.
+func MergeYAML(sources ...Source) ([]byte, error) {
+	merged, err := Merge(sources...)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(merged)
+}
+
+// uniqueName returns name if it isn't already in taken, otherwise the
+// first `<name>-2`, `<name>-3`, ... suffix that isn't.
+func uniqueName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// LoadFromSecret lists every Secret in namespace matching selector — the
+// operator's convention labels workload-cluster kubeconfig Secrets so they
+// can be discovered this way — extracts a kubeconfig from each one via
+// SecretSource, and merges them into a single KubeConfig. Pair this with a
+// label-selector predicate on the Secret watch in the owning controller so
+// reconciliation re-runs as `*-kubeconfig` Secrets are added or removed,
+// letting the operator register remote clusters dynamically.
+//
+// opts is accepted for forward-compatibility with resolving CA/exec
+// material while loading (see ExtractFluxFieldsByContextWithOptions); it
+// is not yet consulted by the merge itself.
+func LoadFromSecret(ctx context.Context, c client.Client, namespace string, selector labels.Selector, opts LoadOptions) (*KubeConfig, error) {
+	var secretList corev1.SecretList
+	if err := c.List(ctx, &secretList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list kubeconfig secrets in namespace %q: %w", namespace, err)
+	}
+
+	sources := make([]Source, 0, len(secretList.Items))
+	for i := range secretList.Items {
+		src, err := SecretSource(&secretList.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	return Merge(sources...)
+}
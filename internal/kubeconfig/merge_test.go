@@ -0,0 +1,192 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const mergeSourceAYAML = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0MTIzCi0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://cluster-a:6443
+  name: workload
+contexts:
+- context:
+    cluster: workload
+    user: admin
+  name: workload
+current-context: workload
+users:
+- name: admin
+  user:
+    token: token-a`
+
+const mergeSourceBYAML = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0NDU2Ci0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://cluster-b:6443
+  name: workload
+contexts:
+- context:
+    cluster: workload
+    user: admin
+  name: workload
+current-context: workload
+users:
+- name: admin
+  user:
+    token: token-b`
+
+func TestMergeRenamesOnCollision(t *testing.T) {
+	merged, err := Merge(YAMLSource("a", mergeSourceAYAML), YAMLSource("b", mergeSourceBYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(merged.Clusters) != 2 {
+		t.Fatalf("expected 2 clusters, got %d", len(merged.Clusters))
+	}
+	if merged.Clusters[0].Name != "workload" {
+		t.Errorf("expected first cluster name %q, got %q", "workload", merged.Clusters[0].Name)
+	}
+	if merged.Clusters[1].Name != "workload-2" {
+		t.Errorf("expected second cluster name %q, got %q", "workload-2", merged.Clusters[1].Name)
+	}
+
+	if len(merged.Contexts) != 2 {
+		t.Fatalf("expected 2 contexts, got %d", len(merged.Contexts))
+	}
+	if merged.Contexts[1].Context.Cluster != "workload-2" {
+		t.Errorf("expected renamed context to reference %q, got %q", "workload-2", merged.Contexts[1].Context.Cluster)
+	}
+	if merged.Contexts[1].Context.User != "admin-2" {
+		t.Errorf("expected renamed context to reference %q, got %q", "admin-2", merged.Contexts[1].Context.User)
+	}
+
+	// The first source's current-context wins.
+	if merged.CurrentContext != "workload" {
+		t.Errorf("expected current-context %q, got %q", "workload", merged.CurrentContext)
+	}
+}
+
+func TestMergeYAML(t *testing.T) {
+	data, err := MergeYAML(YAMLSource("a", mergeSourceAYAML), YAMLSource("b", mergeSourceBYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := string(data)
+	for _, key := range []string{"current-context:", "certificate-authority-data:"} {
+		if !containsSubstring(out, key) {
+			t.Errorf("expected merged YAML to contain kubeconfig key %q, got:\n%s", key, out)
+		}
+	}
+	for _, name := range []string{"CurrentContext:", "CertificateAuthorityData:", "Clusters:"} {
+		if containsSubstring(out, name) {
+			t.Errorf("expected merged YAML to use kubeconfig key names, not the Go field name %q, got:\n%s", name, out)
+		}
+	}
+
+	merged, err := parseKubeConfig(out)
+	if err != nil {
+		t.Fatalf("failed to re-parse merged YAML: %v", err)
+	}
+	if merged.CurrentContext != "workload" {
+		t.Errorf("expected current-context %q to survive the round-trip, got %q", "workload", merged.CurrentContext)
+	}
+}
+
+func TestMergeInvalidSource(t *testing.T) {
+	_, err := Merge(YAMLSource("broken", "this is not valid yaml: ["))
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if !containsSubstring(err.Error(), `source "broken"`) {
+		t.Errorf("expected error to name the offending source, got: %v", err)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubeconfig.yaml")
+	if err := os.WriteFile(path, []byte(mergeSourceAYAML), 0o600); err != nil {
+		t.Fatalf("failed to write kubeconfig file: %v", err)
+	}
+
+	src, err := FileSource(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if src.YAML != mergeSourceAYAML {
+		t.Errorf("expected file contents to round-trip through FileSource")
+	}
+}
+
+func TestSecretSource(t *testing.T) {
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		expectError   bool
+		errorContains string
+	}{
+		{
+			name: "value key",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload-kubeconfig", Namespace: "flux-system"},
+				Data:       map[string][]byte{"value": []byte(mergeSourceAYAML)},
+			},
+		},
+		{
+			name: "capi kubeconfig key",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload-kubeconfig", Namespace: "flux-system"},
+				Data:       map[string][]byte{"kubeconfig": []byte(mergeSourceAYAML)},
+			},
+		},
+		{
+			name: "no recognized key",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload-kubeconfig", Namespace: "flux-system"},
+				Data:       map[string][]byte{"unexpected": []byte(mergeSourceAYAML)},
+			},
+			expectError:   true,
+			errorContains: "no",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := SecretSource(tt.secret)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+					return
+				}
+				if tt.errorContains != "" && !containsSubstring(err.Error(), tt.errorContains) {
+					t.Errorf("expected error to contain %q, got: %v", tt.errorContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if src.Name != "flux-system/workload-kubeconfig" {
+				t.Errorf("expected source name %q, got %q", "flux-system/workload-kubeconfig", src.Name)
+			}
+			if src.YAML != mergeSourceAYAML {
+				t.Errorf("expected source YAML to match secret data")
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultValidateTimeout bounds a Validate call when ValidateOptions.Timeout
+// is unset.
+const defaultValidateTimeout = 10 * time.Second
+
+// defaultValidatePath is the API server endpoint probed when
+// ValidateOptions.Path is unset. It requires no authentication on a stock
+// kube-apiserver, so it's a reasonable default even without credentials.
+const defaultValidatePath = "/version"
+
+// ValidateOptions controls how Validate reaches a cluster's API server.
+type ValidateOptions struct {
+	// ClientCertificateData and ClientKeyData, if both set, authenticate
+	// the TLS handshake with a client certificate.
+	ClientCertificateData string
+	ClientKeyData         string
+
+	// BearerToken, if set, is sent as an Authorization: Bearer header.
+	BearerToken string
+
+	// Path is the API server endpoint to GET after the TLS handshake
+	// succeeds. Defaults to defaultValidatePath.
+	Path string
+
+	// Timeout bounds the whole dial+handshake+request. Defaults to
+	// defaultValidateTimeout if zero.
+	Timeout time.Duration
+}
+
+// ValidateErrorKind categorizes why Validate failed, so operators can tell
+// "the cluster is down" from "the CA bundle is stale" at a glance.
+type ValidateErrorKind string
+
+const (
+	ValidateErrorConfig ValidateErrorKind = "config"
+	ValidateErrorDNS    ValidateErrorKind = "dns"
+	ValidateErrorTCP    ValidateErrorKind = "tcp"
+	ValidateErrorTLS    ValidateErrorKind = "tls"
+	ValidateErrorAuth   ValidateErrorKind = "auth"
+	ValidateErrorOther  ValidateErrorKind = "other"
+)
+
+// ValidateError is returned by Validate. It carries enough detail to
+// distinguish DNS, TCP, TLS/CA, and auth failures, and — for TLS/CA
+// mismatches — the offered certificate's Subject/SAN alongside the
+// configured CA's Subject, which is the detail operators need to debug a
+// CAPI config whose server address is a floating VIP.
+type ValidateError struct {
+	Kind        ValidateErrorKind
+	ClusterName string
+	Err         error
+
+	// StatusCode is set when Kind is ValidateErrorAuth.
+	StatusCode int
+
+	// OfferedCertSubject, OfferedCertDNSNames, and ConfiguredCASubject are
+	// set on a best-effort basis when Kind is ValidateErrorTLS.
+	OfferedCertSubject  string
+	OfferedCertDNSNames []string
+	ConfiguredCASubject string
+}
+
+func (e *ValidateError) Error() string {
+	switch e.Kind {
+	case ValidateErrorAuth:
+		return fmt.Sprintf("cluster %q: API server returned HTTP %d: %v", e.ClusterName, e.StatusCode, e.Err)
+	case ValidateErrorTLS:
+		if e.OfferedCertSubject != "" {
+			return fmt.Sprintf("cluster %q: TLS verification failed: %v (server offered cert subject=%q SAN=%v, configured CA subject=%q)",
+				e.ClusterName, e.Err, e.OfferedCertSubject, e.OfferedCertDNSNames, e.ConfiguredCASubject)
+		}
+		return fmt.Sprintf("cluster %q: TLS verification failed: %v", e.ClusterName, e.Err)
+	default:
+		return fmt.Sprintf("cluster %q: %s: %v", e.ClusterName, e.Kind, e.Err)
+	}
+}
+
+func (e *ValidateError) Unwrap() error {
+	return e.Err
+}
+
+// Validate dials cd.Server, performs a TLS handshake verified against
+// cd.CACert, and issues a GET against opts.Path (default /version) to
+// confirm the API server actually answers. It returns nil only on a 2xx
+// response; every other outcome is a *ValidateError identifying whether
+// the failure was DNS, TCP, TLS/CA, or an auth (401/403) response.
+func Validate(ctx context.Context, cd ClusterData, opts ValidateOptions) error {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultValidateTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM([]byte(cd.CACert)) {
+		return &ValidateError{Kind: ValidateErrorConfig, ClusterName: cd.Name, Err: fmt.Errorf("failed to parse configured CA certificate")}
+	}
+
+	tlsConfig := &tls.Config{RootCAs: caPool, MinVersion: tls.VersionTLS12}
+	if opts.ClientCertificateData != "" && opts.ClientKeyData != "" {
+		cert, err := tls.X509KeyPair([]byte(opts.ClientCertificateData), []byte(opts.ClientKeyData))
+		if err != nil {
+			return &ValidateError{Kind: ValidateErrorConfig, ClusterName: cd.Name, Err: fmt.Errorf("failed to load client certificate: %w", err)}
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	path := opts.Path
+	if path == "" {
+		path = defaultValidatePath
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cd.Server+path, nil)
+	if err != nil {
+		return &ValidateError{Kind: ValidateErrorConfig, ClusterName: cd.Name, Err: fmt.Errorf("failed to build request for %q: %w", cd.Server, err)}
+	}
+	if opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return classifyDialError(ctx, cd, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &ValidateError{Kind: ValidateErrorAuth, ClusterName: cd.Name, StatusCode: resp.StatusCode, Err: fmt.Errorf("authentication rejected")}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &ValidateError{Kind: ValidateErrorOther, ClusterName: cd.Name, Err: fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// classifyDialError turns the opaque error returned by http.Client into a
+// *ValidateError identifying whether the underlying cause was a DNS
+// failure, a TCP failure, or a TLS/CA mismatch — for the latter, it
+// attempts a second, insecure-skip-verify dial purely to capture the
+// offered certificate's Subject/SAN for the error message.
+func classifyDialError(ctx context.Context, cd ClusterData, dialErr error) error {
+	var dnsErr *net.DNSError
+	if errors.As(dialErr, &dnsErr) {
+		return &ValidateError{Kind: ValidateErrorDNS, ClusterName: cd.Name, Err: dialErr}
+	}
+
+	var certErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+	if errors.As(dialErr, &certErr) || errors.As(dialErr, &hostErr) || errors.As(dialErr, &certInvalidErr) {
+		verr := &ValidateError{Kind: ValidateErrorTLS, ClusterName: cd.Name, Err: dialErr}
+		if subject := caCertSubject(cd.CACert); subject != "" {
+			verr.ConfiguredCASubject = subject
+		}
+		if cert := fetchOfferedCert(ctx, cd.Server); cert != nil {
+			verr.OfferedCertSubject = cert.Subject.String()
+			verr.OfferedCertDNSNames = cert.DNSNames
+		}
+		return verr
+	}
+
+	var opErr *net.OpError
+	if errors.As(dialErr, &opErr) {
+		return &ValidateError{Kind: ValidateErrorTCP, ClusterName: cd.Name, Err: dialErr}
+	}
+
+	return &ValidateError{Kind: ValidateErrorOther, ClusterName: cd.Name, Err: dialErr}
+}
+
+// fetchOfferedCert dials server with certificate verification disabled,
+// purely to read back the leaf certificate it presents for inclusion in a
+// TLS/CA mismatch error. Failures are swallowed — this is best-effort
+// diagnostic detail, not load-bearing for the validation result.
+func fetchOfferedCert(ctx context.Context, server string) *x509.Certificate {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}} //nolint:gosec // diagnostic-only dial, never used to authenticate
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil
+	}
+	return certs[0]
+}
+
+// caCertSubject parses a PEM-encoded CA certificate and returns its
+// Subject, for inclusion in a TLS/CA mismatch error.
+func caCertSubject(caCertPEM string) string {
+	block, _ := pem.Decode([]byte(caCertPEM))
+	if block == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return cert.Subject.String()
+}
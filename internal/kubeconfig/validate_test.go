@@ -0,0 +1,162 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serverCAPEM(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	if len(srv.Certificate().Raw) == 0 {
+		t.Fatal("test server has no certificate")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+}
+
+// newTLSServer starts an httptest TLS server with its own freshly
+// generated self-signed certificate, so two servers created this way have
+// distinct CAs (unlike httptest.NewTLSServer, which reuses one fixed
+// built-in certificate for every server).
+func newTLSServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	return srv
+}
+
+func TestValidateSuccess(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cd := ClusterData{Name: "test", Server: srv.URL, CACert: serverCAPEM(t, srv)}
+
+	if err := Validate(context.Background(), cd, ValidateOptions{Timeout: 5 * time.Second}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateAuthRejected(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	cd := ClusterData{Name: "test", Server: srv.URL, CACert: serverCAPEM(t, srv)}
+
+	err := Validate(context.Background(), cd, ValidateOptions{Timeout: 5 * time.Second})
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+
+	var verr *ValidateError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidateError, got: %T (%v)", err, err)
+	}
+	if verr.Kind != ValidateErrorAuth {
+		t.Errorf("expected kind %q, got %q", ValidateErrorAuth, verr.Kind)
+	}
+	if verr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, verr.StatusCode)
+	}
+}
+
+func TestValidateCAMismatch(t *testing.T) {
+	srv := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer srv.Close()
+
+	otherSrv := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer otherSrv.Close()
+
+	// Validate srv using otherSrv's CA: a guaranteed mismatch.
+	cd := ClusterData{Name: "test", Server: srv.URL, CACert: serverCAPEM(t, otherSrv)}
+
+	err := Validate(context.Background(), cd, ValidateOptions{Timeout: 5 * time.Second})
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+
+	var verr *ValidateError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidateError, got: %T (%v)", err, err)
+	}
+	if verr.Kind != ValidateErrorTLS {
+		t.Errorf("expected kind %q, got %q", ValidateErrorTLS, verr.Kind)
+	}
+	if verr.OfferedCertSubject == "" {
+		t.Errorf("expected offered cert subject to be captured for diagnostics")
+	}
+}
+
+func TestValidateTCPFailure(t *testing.T) {
+	// Any well-formed CA works here since the dial fails before TLS even
+	// starts; borrow one from a throwaway server.
+	placeholder := newTLSServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	caCert := serverCAPEM(t, placeholder)
+	placeholder.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listens here now, so dialing it should be refused
+
+	cd := ClusterData{Name: "test", Server: "https://" + addr, CACert: caCert}
+
+	err = Validate(context.Background(), cd, ValidateOptions{Timeout: 2 * time.Second})
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+
+	var verr *ValidateError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidateError, got: %T (%v)", err, err)
+	}
+	if verr.Kind != ValidateErrorTCP {
+		t.Errorf("expected kind %q, got %q", ValidateErrorTCP, verr.Kind)
+	}
+}
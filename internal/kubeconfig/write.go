@@ -0,0 +1,182 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Marshal produces canonical kubeconfig YAML for cfg. The YAML is derived
+// from the JSON encoding and every mapping key is emitted in sorted order,
+// so two semantically equal KubeConfigs always marshal to the same bytes —
+// safe to reconcile into a Secret without spurious diffs.
+func Marshal(cfg *KubeConfig) ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kubeconfig: %w", err)
+	}
+	return data, nil
+}
+
+// ClusterOptions describes the fields to set on a cluster entry via
+// SetCluster. Only non-nil fields are applied, so a partial update doesn't
+// clobber the rest of an existing entry.
+//
+// CertificateAuthorityData and CertificateAuthorityFile are mutually
+// exclusive: setting one clears the other, matching `kubectl config
+// set-cluster`'s behaviour of embedding a CA (--embed-certs) versus
+// referencing it by path.
+type ClusterOptions struct {
+	Server                   *string
+	CertificateAuthorityData []byte
+	CertificateAuthorityFile *string
+}
+
+// SetCluster creates or updates the named cluster entry, matching `kubectl
+// config set-cluster`.
+func (cfg *KubeConfig) SetCluster(name string, opts ClusterOptions) {
+	cluster := cfg.findOrCreateCluster(name)
+
+	if opts.Server != nil {
+		cluster.Cluster.Server = *opts.Server
+	}
+	if opts.CertificateAuthorityData != nil {
+		cluster.Cluster.CertificateAuthorityData = base64.StdEncoding.EncodeToString(opts.CertificateAuthorityData)
+		cluster.Cluster.CertificateAuthority = ""
+	}
+	if opts.CertificateAuthorityFile != nil {
+		cluster.Cluster.CertificateAuthority = *opts.CertificateAuthorityFile
+		cluster.Cluster.CertificateAuthorityData = ""
+	}
+}
+
+// UserOptions describes the fields to set on a user entry via SetUser.
+// Only non-nil fields are applied.
+//
+// ClientCertificateData/ClientCertificateFile and ClientKeyData/ClientKeyFile
+// are each mutually exclusive pairs: setting one clears the other, matching
+// `kubectl config set-credentials`'s --embed-certs behaviour.
+type UserOptions struct {
+	ClientCertificateData []byte
+	ClientCertificateFile *string
+	ClientKeyData         []byte
+	ClientKeyFile         *string
+	Token                 *string
+	Exec                  *ExecConfig
+}
+
+// SetUser creates or updates the named user entry, matching `kubectl
+// config set-credentials`.
+func (cfg *KubeConfig) SetUser(name string, opts UserOptions) {
+	user := cfg.findOrCreateUser(name)
+
+	if opts.ClientCertificateData != nil {
+		user.User.ClientCertificateData = base64.StdEncoding.EncodeToString(opts.ClientCertificateData)
+		user.User.ClientCertificate = ""
+	}
+	if opts.ClientCertificateFile != nil {
+		user.User.ClientCertificate = *opts.ClientCertificateFile
+		user.User.ClientCertificateData = ""
+	}
+	if opts.ClientKeyData != nil {
+		user.User.ClientKeyData = base64.StdEncoding.EncodeToString(opts.ClientKeyData)
+		user.User.ClientKey = ""
+	}
+	if opts.ClientKeyFile != nil {
+		user.User.ClientKey = *opts.ClientKeyFile
+		user.User.ClientKeyData = ""
+	}
+	if opts.Token != nil {
+		user.User.Token = *opts.Token
+	}
+	if opts.Exec != nil {
+		user.User.Exec = opts.Exec
+	}
+}
+
+// SetContext creates or updates the named context entry, matching `kubectl
+// config set-context`.
+func (cfg *KubeConfig) SetContext(name string, context Context) {
+	for i := range cfg.Contexts {
+		if cfg.Contexts[i].Name == name {
+			cfg.Contexts[i].Context = context
+			return
+		}
+	}
+	cfg.Contexts = append(cfg.Contexts, NamedContext{Name: name, Context: context})
+}
+
+// SetCurrentContext sets current-context to name, matching `kubectl config
+// use-context`. It returns an error if no context with that name exists.
+func (cfg *KubeConfig) SetCurrentContext(name string) error {
+	if _, ok := findContext(*cfg, name); !ok {
+		return fmt.Errorf("context %q not found in kubeconfig", name)
+	}
+	cfg.CurrentContext = name
+	return nil
+}
+
+// RemoveCluster deletes the named cluster entry, matching `kubectl config
+// delete-cluster`. It does not touch contexts that reference the removed
+// cluster, the same as kubectl — callers that want a consistent kubeconfig
+// should remove or repoint those contexts too. Returns an error if no
+// cluster with that name exists.
+func (cfg *KubeConfig) RemoveCluster(name string) error {
+	for i := range cfg.Clusters {
+		if cfg.Clusters[i].Name == name {
+			cfg.Clusters = append(cfg.Clusters[:i], cfg.Clusters[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("cluster %q not found in kubeconfig", name)
+}
+
+// RenameContext renames context oldName to newName, matching `kubectl
+// config rename-context`, and updates current-context if it pointed at
+// oldName. Returns an error if oldName doesn't exist or newName is already
+// taken.
+func (cfg *KubeConfig) RenameContext(oldName, newName string) error {
+	if _, ok := findContext(*cfg, newName); ok {
+		return fmt.Errorf("context %q already exists in kubeconfig", newName)
+	}
+
+	for i := range cfg.Contexts {
+		if cfg.Contexts[i].Name == oldName {
+			cfg.Contexts[i].Name = newName
+			if cfg.CurrentContext == oldName {
+				cfg.CurrentContext = newName
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("context %q not found in kubeconfig", oldName)
+}
+
+// findOrCreateCluster returns a pointer to the named cluster entry,
+// appending a new zero-value one if it doesn't exist yet.
+func (cfg *KubeConfig) findOrCreateCluster(name string) *NamedCluster {
+	for i := range cfg.Clusters {
+		if cfg.Clusters[i].Name == name {
+			return &cfg.Clusters[i]
+		}
+	}
+	cfg.Clusters = append(cfg.Clusters, NamedCluster{Name: name})
+	return &cfg.Clusters[len(cfg.Clusters)-1]
+}
+
+// findOrCreateUser returns a pointer to the named user entry, appending a
+// new zero-value one if it doesn't exist yet.
+func (cfg *KubeConfig) findOrCreateUser(name string) *NamedUser {
+	for i := range cfg.Users {
+		if cfg.Users[i].Name == name {
+			return &cfg.Users[i]
+		}
+	}
+	cfg.Users = append(cfg.Users, NamedUser{Name: name})
+	return &cfg.Users[len(cfg.Users)-1]
+}
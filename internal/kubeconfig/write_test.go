@@ -0,0 +1,505 @@
+// Copyright 2024 Stefan Prodan.
+// SPDX-License-Identifier: AGPL-3.0
+
+package kubeconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	cfg, err := parseKubeConfig(multiContextKubeconfigYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reparsed, err := parseKubeConfig(string(data))
+	if err != nil {
+		t.Fatalf("failed to re-parse marshaled kubeconfig: %v", err)
+	}
+
+	if len(reparsed.Clusters) != len(cfg.Clusters) {
+		t.Errorf("expected %d clusters after round-trip, got %d", len(cfg.Clusters), len(reparsed.Clusters))
+	}
+
+	const expectedCurrentContext = "workload-admin@workload"
+	if cfg.CurrentContext != expectedCurrentContext {
+		t.Fatalf("fixture's current-context changed out from under this test: expected %q, got %q", expectedCurrentContext, cfg.CurrentContext)
+	}
+	if reparsed.CurrentContext != expectedCurrentContext {
+		t.Errorf("expected current-context %q to survive marshal round-trip, got %q", expectedCurrentContext, reparsed.CurrentContext)
+	}
+}
+
+// richKubeconfigYAML exercises every field KubeConfig models, including the
+// less common ones (proxy-url, insecure-skip-tls-verify, tls-server-name,
+// namespace, auth-provider, tokenFile, username, password, as) that a
+// field-by-field round-trip check could miss if one were silently dropped
+// by the struct definitions.
+const richKubeconfigYAML = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCk1JSUN0ZXN0MTIzCi0tLS0tRU5EIENFUlRJRklDQVRFLS0tLS0=
+    server: https://workload-cluster:6443
+    proxy-url: https://proxy.example.com:8080
+    insecure-skip-tls-verify: true
+    tls-server-name: workload-cluster.internal
+  name: workload
+contexts:
+- context:
+    cluster: workload
+    user: workload-admin
+    namespace: flux-system
+  name: workload-admin@workload
+current-context: workload-admin@workload
+users:
+- name: workload-admin
+  user:
+    token: sometoken123
+    tokenFile: /var/run/secrets/token
+    username: admin
+    password: hunter2
+    as: impersonated-user
+    auth-provider:
+      name: oidc
+      config:
+        client-id: flux
+        idp-issuer-url: https://issuer.example.com
+`
+
+func TestMarshalRoundTrip_FieldLevelFidelity(t *testing.T) {
+	cfg, err := parseKubeConfig(richKubeconfigYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reparsed, err := parseKubeConfig(string(data))
+	if err != nil {
+		t.Fatalf("failed to re-parse marshaled kubeconfig: %v", err)
+	}
+
+	if !reflect.DeepEqual(cfg, reparsed) {
+		t.Errorf("expected parse->marshal->reparse to be lossless.\noriginal: %+v\nreparsed: %+v", cfg, reparsed)
+	}
+}
+
+func TestSetClusterCAInvariant(t *testing.T) {
+	var cfg KubeConfig
+	cfg.SetCluster("workload", ClusterOptions{CertificateAuthorityFile: strPtr("/etc/kubernetes/ca.crt")})
+
+	cluster, ok := findCluster(cfg, "workload")
+	if !ok {
+		t.Fatal("expected cluster to be created")
+	}
+	if cluster.Cluster.CertificateAuthority != "/etc/kubernetes/ca.crt" {
+		t.Errorf("expected CA file to be set, got %q", cluster.Cluster.CertificateAuthority)
+	}
+
+	cfg.SetCluster("workload", ClusterOptions{CertificateAuthorityData: []byte("fake-ca-bytes")})
+
+	cluster, ok = findCluster(cfg, "workload")
+	if !ok {
+		t.Fatal("expected cluster to still exist")
+	}
+	if cluster.Cluster.CertificateAuthority != "" {
+		t.Errorf("expected CA file to be cleared once CA data was set, got %q", cluster.Cluster.CertificateAuthority)
+	}
+	if cluster.Cluster.CertificateAuthorityData == "" {
+		t.Errorf("expected CA data to be set")
+	}
+
+	cfg.SetCluster("workload", ClusterOptions{CertificateAuthorityFile: strPtr("/etc/kubernetes/ca2.crt")})
+
+	cluster, ok = findCluster(cfg, "workload")
+	if !ok {
+		t.Fatal("expected cluster to still exist")
+	}
+	if cluster.Cluster.CertificateAuthorityData != "" {
+		t.Errorf("expected CA data to be cleared once CA file was set, got %q", cluster.Cluster.CertificateAuthorityData)
+	}
+}
+
+func TestSetUserCredentialInvariants(t *testing.T) {
+	var cfg KubeConfig
+	cfg.SetUser("admin", UserOptions{
+		ClientCertificateFile: strPtr("/etc/kubernetes/client.crt"),
+		ClientKeyFile:         strPtr("/etc/kubernetes/client.key"),
+	})
+
+	user, ok := findUser(cfg, "admin")
+	if !ok {
+		t.Fatal("expected user to be created")
+	}
+	if user.User.ClientCertificate == "" || user.User.ClientKey == "" {
+		t.Fatalf("expected client cert/key files to be set, got %+v", user.User)
+	}
+
+	cfg.SetUser("admin", UserOptions{
+		ClientCertificateData: []byte("fake-cert-bytes"),
+		ClientKeyData:         []byte("fake-key-bytes"),
+	})
+
+	user, ok = findUser(cfg, "admin")
+	if !ok {
+		t.Fatal("expected user to still exist")
+	}
+	if user.User.ClientCertificate != "" || user.User.ClientKey != "" {
+		t.Errorf("expected client cert/key files to be cleared once data was set, got %+v", user.User)
+	}
+	if user.User.ClientCertificateData == "" || user.User.ClientKeyData == "" {
+		t.Errorf("expected client cert/key data to be set")
+	}
+}
+
+func TestSetContextAndCurrentContext(t *testing.T) {
+	var cfg KubeConfig
+	cfg.SetCluster("workload", ClusterOptions{Server: strPtr("https://workload:6443")})
+	cfg.SetUser("admin", UserOptions{Token: strPtr("sometoken")})
+	cfg.SetContext("workload-admin", Context{Cluster: "workload", User: "admin"})
+
+	if err := cfg.SetCurrentContext("workload-admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CurrentContext != "workload-admin" {
+		t.Errorf("expected current-context to be set, got %q", cfg.CurrentContext)
+	}
+
+	if err := cfg.SetCurrentContext("does-not-exist"); err == nil {
+		t.Fatal("expected error for unknown context")
+	}
+
+	// Updating an existing context in place shouldn't create a duplicate.
+	cfg.SetContext("workload-admin", Context{Cluster: "workload", User: "admin"})
+	if len(cfg.Contexts) != 1 {
+		t.Errorf("expected 1 context after re-setting, got %d", len(cfg.Contexts))
+	}
+}
+
+func TestRemoveCluster(t *testing.T) {
+	var cfg KubeConfig
+	cfg.SetCluster("workload", ClusterOptions{Server: strPtr("https://workload:6443")})
+
+	if err := cfg.RemoveCluster("workload"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Clusters) != 0 {
+		t.Errorf("expected cluster to be removed, got %d clusters", len(cfg.Clusters))
+	}
+
+	if err := cfg.RemoveCluster("workload"); err == nil {
+		t.Fatal("expected error removing a cluster that no longer exists")
+	}
+}
+
+func TestRenameContext(t *testing.T) {
+	var cfg KubeConfig
+	cfg.SetContext("old-name", Context{Cluster: "workload", User: "admin"})
+	if err := cfg.SetCurrentContext("old-name"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := cfg.RenameContext("old-name", "new-name"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := findContext(cfg, "old-name"); ok {
+		t.Error("expected old context name to be gone")
+	}
+	if _, ok := findContext(cfg, "new-name"); !ok {
+		t.Error("expected new context name to exist")
+	}
+	if cfg.CurrentContext != "new-name" {
+		t.Errorf("expected current-context to follow the rename, got %q", cfg.CurrentContext)
+	}
+
+	if err := cfg.RenameContext("does-not-exist", "whatever"); err == nil {
+		t.Fatal("expected error renaming an unknown context")
+	}
+
+	cfg.SetContext("taken", Context{Cluster: "workload", User: "admin"})
+	if err := cfg.RenameContext("new-name", "taken"); err == nil {
+		t.Fatal("expected error renaming onto an existing context name")
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+// Seed corpus drawn from the shapes real cluster-api, kubeadm, EKS, GKE, and
+// AKS tooling produce, so the fuzz target exercises parsers/writers against
+// more than one vendor's conventions.
+const fuzzSeedCAPI = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: ZmFrZS1jYS1kYXRh
+    server: https://capi-workload.example.com:6443
+  name: capi-workload
+contexts:
+- context:
+    cluster: capi-workload
+    user: capi-workload-admin
+  name: capi-workload-admin@capi-workload
+current-context: capi-workload-admin@capi-workload
+users:
+- name: capi-workload-admin
+  user:
+    client-certificate-data: ZmFrZS1jZXJ0LWRhdGE=
+    client-key-data: ZmFrZS1rZXktZGF0YQ==
+`
+
+const fuzzSeedKubeadm = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: a3ViZWFkbS1jYS1kYXRh
+    server: https://10.0.0.1:6443
+  name: kubernetes
+contexts:
+- context:
+    cluster: kubernetes
+    user: kubernetes-admin
+  name: kubernetes-admin@kubernetes
+current-context: kubernetes-admin@kubernetes
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: a3ViZWFkbS1jZXJ0LWRhdGE=
+    client-key-data: a3ViZWFkbS1rZXktZGF0YQ==
+`
+
+const fuzzSeedEKS = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: ZWtzLWNhLWRhdGE=
+    server: https://ABCDEF123.gr7.us-east-1.eks.amazonaws.com
+  name: arn:aws:eks:us-east-1:123456789012:cluster/prod
+contexts:
+- context:
+    cluster: arn:aws:eks:us-east-1:123456789012:cluster/prod
+    user: arn:aws:eks:us-east-1:123456789012:cluster/prod
+  name: arn:aws:eks:us-east-1:123456789012:cluster/prod
+current-context: arn:aws:eks:us-east-1:123456789012:cluster/prod
+users:
+- name: arn:aws:eks:us-east-1:123456789012:cluster/prod
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args:
+      - eks
+      - get-token
+      - --cluster-name
+      - prod
+`
+
+const fuzzSeedGKE = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: Z2tlLWNhLWRhdGE=
+    server: https://34.1.2.3
+  name: gke_my-project_us-central1_prod
+contexts:
+- context:
+    cluster: gke_my-project_us-central1_prod
+    user: gke_my-project_us-central1_prod
+  name: gke_my-project_us-central1_prod
+current-context: gke_my-project_us-central1_prod
+users:
+- name: gke_my-project_us-central1_prod
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: gke-gcloud-auth-plugin
+`
+
+const fuzzSeedAKS = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: YWtzLWNhLWRhdGE=
+    server: https://prod-aks-dns-abc123.hcp.eastus.azmk8s.io:443
+  name: prod-aks
+contexts:
+- context:
+    cluster: prod-aks
+    user: clusterUser_prod-rg_prod-aks
+  name: prod-aks
+current-context: prod-aks
+users:
+- name: clusterUser_prod-rg_prod-aks
+  user:
+    token: YWtzLXRva2Vu
+`
+
+// fuzzSeedRich carries every cluster/context/user field KubeConfig models,
+// including the less common ones (proxy-url, insecure-skip-tls-verify,
+// tls-server-name, namespace, auth-provider, tokenFile, username, password,
+// as) that a mutation round-trip targeting only the fuzzed cluster/user/
+// context could otherwise miss if one of them were silently dropped.
+const fuzzSeedRich = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: cmljaC1jYS1kYXRh
+    server: https://rich-cluster:6443
+    proxy-url: https://proxy.example.com:8080
+    insecure-skip-tls-verify: true
+    tls-server-name: rich-cluster.internal
+  name: rich-cluster
+contexts:
+- context:
+    cluster: rich-cluster
+    user: rich-admin
+    namespace: flux-system
+  name: rich-admin@rich-cluster
+current-context: rich-admin@rich-cluster
+users:
+- name: rich-admin
+  user:
+    token: sometoken123
+    tokenFile: /var/run/secrets/token
+    username: admin
+    password: hunter2
+    as: impersonated-user
+    auth-provider:
+      name: oidc
+      config:
+        client-id: flux
+        idp-issuer-url: https://issuer.example.com
+`
+
+// FuzzMarshalMutateRoundTrip parses a kubeconfig, applies a handful of
+// mutation helpers to it, marshals the result, and re-parses it, checking
+// that the writer never produces a kubeconfig the parser itself rejects,
+// that current-context and the mutated cluster/user survive the round-trip,
+// and that the CA/cert/key mutual-exclusivity invariants always hold.
+func FuzzMarshalMutateRoundTrip(f *testing.F) {
+	for _, seed := range []string{fuzzSeedCAPI, fuzzSeedKubeadm, fuzzSeedEKS, fuzzSeedGKE, fuzzSeedAKS, fuzzSeedRich} {
+		f.Add(seed, "fuzzed-cluster", "fuzzed-user", "fuzzed-context")
+	}
+
+	f.Fuzz(func(t *testing.T, kubeconfigYAML, clusterName, userName, contextName string) {
+		cfg, err := parseKubeConfig(kubeconfigYAML)
+		if err != nil {
+			t.Skip()
+		}
+
+		if clusterName == "" || userName == "" || contextName == "" {
+			t.Skip()
+		}
+
+		// Snapshot the clusters/contexts/users the mutations below won't
+		// touch, so the round-trip can assert the writer didn't silently
+		// drop any of their fields (e.g. proxy-url, auth-provider) along
+		// the way.
+		untouchedClusters := make([]NamedCluster, 0, len(cfg.Clusters))
+		for _, c := range cfg.Clusters {
+			if c.Name != clusterName {
+				untouchedClusters = append(untouchedClusters, c)
+			}
+		}
+		untouchedContexts := make([]NamedContext, 0, len(cfg.Contexts))
+		for _, c := range cfg.Contexts {
+			if c.Name != contextName {
+				untouchedContexts = append(untouchedContexts, c)
+			}
+		}
+		untouchedUsers := make([]NamedUser, 0, len(cfg.Users))
+		for _, u := range cfg.Users {
+			if u.Name != userName {
+				untouchedUsers = append(untouchedUsers, u)
+			}
+		}
+		// Kubeconfig names travel through sigs.k8s.io/yaml's JSON
+		// intermediate representation, which (like YAML itself) requires
+		// valid UTF-8 text; invalid UTF-8 isn't a meaningful kubeconfig
+		// name, so it's out of scope for this round-trip property.
+		if !utf8.ValidString(clusterName) || !utf8.ValidString(userName) || !utf8.ValidString(contextName) {
+			t.Skip()
+		}
+
+		cfg.SetCluster(clusterName, ClusterOptions{
+			Server:                   strPtr("https://" + strings.TrimSpace(clusterName) + ":6443"),
+			CertificateAuthorityData: []byte("fuzzed-ca-bytes"),
+		})
+		cfg.SetUser(userName, UserOptions{Token: strPtr("fuzzed-token")})
+		cfg.SetContext(contextName, Context{Cluster: clusterName, User: userName})
+		if err := cfg.SetCurrentContext(contextName); err != nil {
+			t.Fatalf("unexpected error setting current-context to a context just created: %v", err)
+		}
+
+		data, err := Marshal(&cfg)
+		if err != nil {
+			t.Fatalf("Marshal returned an error for a previously-parsed kubeconfig: %v", err)
+		}
+
+		reparsed, err := parseKubeConfig(string(data))
+		if err != nil {
+			t.Fatalf("marshaled kubeconfig failed to re-parse: %v", err)
+		}
+
+		if reparsed.CurrentContext != contextName {
+			t.Fatalf("expected current-context %q to survive round-trip, got %q", contextName, reparsed.CurrentContext)
+		}
+
+		cluster, ok := findCluster(reparsed, clusterName)
+		if !ok {
+			t.Fatalf("expected cluster %q to survive round-trip", clusterName)
+		}
+		if cluster.Cluster.CertificateAuthorityData != "" && cluster.Cluster.CertificateAuthority != "" {
+			t.Fatalf("cluster %q has both CA data and CA file set after round-trip", clusterName)
+		}
+
+		if user, ok := findUser(reparsed, userName); ok {
+			if user.User.ClientCertificateData != "" && user.User.ClientCertificate != "" {
+				t.Fatalf("user %q has both client-certificate data and file set after round-trip", userName)
+			}
+			if user.User.ClientKeyData != "" && user.User.ClientKey != "" {
+				t.Fatalf("user %q has both client-key data and file set after round-trip", userName)
+			}
+		}
+
+		for _, want := range untouchedClusters {
+			got, ok := findCluster(reparsed, want.Name)
+			if !ok {
+				t.Fatalf("expected untouched cluster %q to survive round-trip", want.Name)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("untouched cluster %q lost fields in round-trip: want %+v, got %+v", want.Name, want, got)
+			}
+		}
+		for _, want := range untouchedContexts {
+			got, ok := findContext(reparsed, want.Name)
+			if !ok {
+				t.Fatalf("expected untouched context %q to survive round-trip", want.Name)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("untouched context %q lost fields in round-trip: want %+v, got %+v", want.Name, want, got)
+			}
+		}
+		for _, want := range untouchedUsers {
+			got, ok := findUser(reparsed, want.Name)
+			if !ok {
+				t.Fatalf("expected untouched user %q to survive round-trip", want.Name)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("untouched user %q lost fields in round-trip: want %+v, got %+v", want.Name, want, got)
+			}
+		}
+	})
+}